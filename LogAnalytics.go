@@ -2,25 +2,70 @@ package main
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha256"
+	"compress/gzip"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"math/rand"
 	"net/http"
 	"strconv"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	signatureDateFormat string = time.RFC1123
 	TimeGeneratedFormat string = time.RFC3339
+
+	// DefaultMaxBatchSize caps how many items go into a single POST.
+	DefaultMaxBatchSize int = 100
+	// DefaultMaxBatchBytes caps the uncompressed JSON payload size, well under Azure's 30 MB per-post limit.
+	// The cap is checked against the summed size of the marshaled items; a
+	// single item larger than the cap is still sent, in a batch of its own.
+	DefaultMaxBatchBytes int = 1 << 20 // 1 MiB
+	// DefaultFlushInterval bounds how long an item can sit in the batch before being sent.
+	DefaultFlushInterval time.Duration = 5 * time.Second
+	// DefaultGzipThresholdBytes is the uncompressed payload size above which batches are gzip'd.
+	DefaultGzipThresholdBytes int = 32 * 1024
+	// DefaultMaxRetries is how many times a failed batch is retried before being dropped.
+	DefaultMaxRetries int = 5
+	// DefaultBaseRetryDelay is the backoff delay used after the first failed attempt.
+	DefaultBaseRetryDelay time.Duration = 500 * time.Millisecond
+	// DefaultMaxRetryDelay caps the exponential backoff delay between retries.
+	DefaultMaxRetryDelay time.Duration = 30 * time.Second
+	// DefaultQueueCapacity bounds how many unbatched items may be buffered before FullPolicy kicks in.
+	DefaultQueueCapacity int = 1000
+	// DefaultTimeGeneratedField is the JSON field holding an item's timestamp
+	// when neither AddTo nor Config.Router says otherwise.
+	DefaultTimeGeneratedField string = "TimeGenerated"
+)
+
+// FullPolicy controls what AddContext does when the queue is at DefaultQueueCapacity/QueueCapacity.
+type FullPolicy int
+
+const (
+	// FullPolicyBlock waits for room, honoring ctx cancellation. This is the default.
+	FullPolicyBlock FullPolicy = iota
+	// FullPolicyDropOldest discards the oldest queued item to make room for the new one.
+	FullPolicyDropOldest
+	// FullPolicyDropNewest discards the item being added instead of blocking.
+	FullPolicyDropNewest
+	// FullPolicyError returns ErrQueueFull instead of blocking.
+	FullPolicyError
 )
 
+// ErrQueueFull is returned by AddContext under FullPolicyError, and by
+// FullPolicyDropNewest to tell the caller their item was discarded.
+var ErrQueueFull = errors.New("loganalytics: queue is full")
+
+// ErrShutdown is returned by AddContext/AddTo once Shutdown has started; the
+// queue is closed at that point so no further item can be enqueued.
+var ErrShutdown = errors.New("loganalytics: client is shutting down")
+
 type LogTime time.Time
 
 func (t LogTime)MarshalJSON() ([]byte, error) {
@@ -32,114 +77,568 @@ type LogItem struct {
 	TimeGenerated LogTime `json:"TimeGenerated"`
 }
 
+// Config holds the batching, compression and retry knobs for a LogAnalytics client.
+type Config struct {
+	MaxBatchSize       int
+	MaxBatchBytes      int
+	FlushInterval      time.Duration
+	GzipThresholdBytes int
+
+	// Spool persists batches handed to send() until they are acked, so that
+	// a crash between a batch's flush and its successful POST does not lose
+	// it. Items still in the queue, or not yet flushed into a batch, are not
+	// spooled and are lost on a crash.
+	// Defaults to a MemorySpool, which offers no durability across restarts.
+	Spool Spool
+
+	MaxRetries     int
+	BaseRetryDelay time.Duration
+	MaxRetryDelay  time.Duration
+
+	QueueCapacity int
+	FullPolicy    FullPolicy
+
+	// Logger receives the operational messages this package used to send to
+	// log.Print. Defaults to a Logger that still writes through log.Print.
+	Logger Logger
+	// Metrics receives counters/histograms for enqueued items, sent batches,
+	// bytes, HTTP status classes, retries and send latency. Defaults to a
+	// no-op implementation.
+	Metrics Metrics
+
+	// Router picks the table (logType) and TimeGenerated field name
+	// (timeField) an item sent via AddContext is sharded into, letting one
+	// LogAnalytics instance fan out to multiple tables. Items sent via AddTo
+	// bypass Router. A nil Router sends everything to the transport's own
+	// default table under DefaultTimeGeneratedField.
+	Router func(item interface{}) (logType string, timeField string)
+}
+
+func defaultConfig() Config {
+	return Config{
+		MaxBatchSize:       DefaultMaxBatchSize,
+		MaxBatchBytes:      DefaultMaxBatchBytes,
+		FlushInterval:      DefaultFlushInterval,
+		GzipThresholdBytes: DefaultGzipThresholdBytes,
+		Spool:              NewMemorySpool(),
+		MaxRetries:         DefaultMaxRetries,
+		BaseRetryDelay:     DefaultBaseRetryDelay,
+		MaxRetryDelay:      DefaultMaxRetryDelay,
+		QueueCapacity:      DefaultQueueCapacity,
+		FullPolicy:         FullPolicyBlock,
+		Logger:             stdLogger{},
+		Metrics:            noopMetrics{},
+	}
+}
+
+// queuedItem carries an item alongside the table (logType) and TimeGenerated
+// field name (timeField) it should be sent under, so the worker can group the
+// queue by (logType, timeField) before dispatch.
+type queuedItem struct {
+	item      interface{}
+	logType   string
+	timeField string
+}
+
 type LogAnalytics struct {
-	workspaceId string
-	sharedKey []byte
-	logName string
-	url string
-	queue chan interface{}
+	transport requestBuilder
+	queue chan queuedItem
 	waitGroup sync.WaitGroup
 	httpClient *http.Client
+	config Config
+	batchSeq uint64
+	ctx context.Context
+	cancel context.CancelFunc
+
+	// closeMutex guards closed/the queue close against concurrent enqueue:
+	// enqueue holds a read lock around its send so Shutdown's write lock
+	// can't close the queue out from under it (which would panic).
+	closeMutex sync.RWMutex
+	closed     bool
 }
 
 func NewLogAnalytics(workspaceId string, sharedKey string, logName string) *LogAnalytics {
+	return NewLogAnalyticsWithConfig(workspaceId, sharedKey, logName, defaultConfig())
+}
+
+// NewLogAnalyticsWithConfig is like NewLogAnalytics but allows tuning the batch
+// size/byte thresholds, flush interval and gzip threshold used by the worker.
+func NewLogAnalyticsWithConfig(workspaceId string, sharedKey string, logName string, config Config) *LogAnalytics {
 	key, err := base64.StdEncoding.DecodeString(sharedKey)
 	if err != nil {
 		return nil
 	}
-	logAnalytics := &LogAnalytics{
-		logName: logName,
-		sharedKey: key,
+
+	transport := &classicTransport{
 		workspaceId: workspaceId,
-		url: "https://" + workspaceId + ".ods.opinsights.azure.com/api/logs?api-version=2016-04-01",
-		queue: make(chan interface{}),
+		sharedKey:   key,
+		logName:     logName,
+		url:         "https://" + workspaceId + ".ods.opinsights.azure.com/api/logs?api-version=2016-04-01",
+	}
+
+	return newLogAnalytics(transport, config)
+}
+
+// newLogAnalytics wires up the queue, worker pool and config defaults shared
+// by every backend; only transport differs between them.
+func newLogAnalytics(transport requestBuilder, config Config) *LogAnalytics {
+	if config.MaxBatchSize <= 0 {
+		config.MaxBatchSize = DefaultMaxBatchSize
+	}
+	if config.MaxBatchBytes <= 0 {
+		config.MaxBatchBytes = DefaultMaxBatchBytes
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultFlushInterval
+	}
+	if config.GzipThresholdBytes <= 0 {
+		config.GzipThresholdBytes = DefaultGzipThresholdBytes
+	}
+	if config.Spool == nil {
+		config.Spool = NewMemorySpool()
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = DefaultMaxRetries
+	}
+	if config.BaseRetryDelay <= 0 {
+		config.BaseRetryDelay = DefaultBaseRetryDelay
+	}
+	if config.MaxRetryDelay <= 0 {
+		config.MaxRetryDelay = DefaultMaxRetryDelay
+	}
+	if config.QueueCapacity <= 0 {
+		config.QueueCapacity = DefaultQueueCapacity
+	}
+	if config.Logger == nil {
+		config.Logger = stdLogger{}
+	}
+	if config.Metrics == nil {
+		config.Metrics = noopMetrics{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	logAnalytics := &LogAnalytics{
+		transport: transport,
+		queue: make(chan queuedItem, config.QueueCapacity),
 		httpClient: &http.Client{
 			Timeout: time.Second * 60,
 		},
+		config: config,
+		ctx: ctx,
+		cancel: cancel,
 	}
 
+	logAnalytics.replayPending()
+
+	// Registered synchronously, before the goroutines start, so Shutdown
+	// can't race ahead of worker() calling waitGroup.Add itself and reuse
+	// the waitGroup out from under it.
+	logAnalytics.waitGroup.Add(2)
 	go logAnalytics.worker()
 	go logAnalytics.worker()
 
 	return logAnalytics
 }
 
+// replayPending resends any batch that was spooled but never acked, e.g.
+// because the process was killed mid-retry on a previous run.
+func (this *LogAnalytics)replayPending() {
+	pending, err := this.config.Spool.Pending()
+	if err != nil {
+		this.config.Logger.Errorw("could not read spooled batches", "error", err)
+		return
+	}
+
+	for id, batch := range pending {
+		if id >= this.batchSeq {
+			atomic.StoreUint64(&this.batchSeq, id)
+		}
+		this.waitGroup.Add(1)
+		go func(id uint64, batch SpooledBatch) {
+			defer this.waitGroup.Done()
+			this.sendWithRetry(id, batch.LogType, batch.TimeField, batch.Items)
+		}(id, batch)
+	}
+}
+
+// batchKey groups queued items so that each flushed batch still targets a
+// single (table, TimeGenerated field) pair.
+type batchKey struct {
+	logType   string
+	timeField string
+}
+
 func (this *LogAnalytics)worker() {
-	this.waitGroup.Add(1)
 	defer this.waitGroup.Done()
 
-	for job := range this.queue {
-		this.send(job)
+	ticker := time.NewTicker(this.config.FlushInterval)
+	defer ticker.Stop()
+
+	batches := make(map[batchKey][]interface{})
+	batchBytes := make(map[batchKey]int)
+
+	flushKey := func(key batchKey) {
+		items := batches[key]
+		if len(items) == 0 {
+			return
+		}
+
+		id := atomic.AddUint64(&this.batchSeq, 1)
+		batch := SpooledBatch{LogType: key.logType, TimeField: key.timeField, Items: items}
+		if err := this.config.Spool.Write(id, batch); err != nil {
+			this.config.Logger.Errorw("could not spool batch", "batchId", id, "error", err)
+		}
+
+		// Dispatched asynchronously so a slow or throttled batch can't block
+		// this worker from draining other batch keys or servicing the ticker;
+		// waitGroup still tracks it so Shutdown waits for it before closing
+		// the spool.
+		this.waitGroup.Add(1)
+		go func(id uint64, logType string, timeField string, items []interface{}) {
+			defer this.waitGroup.Done()
+			this.sendWithRetry(id, logType, timeField, items)
+		}(id, key.logType, key.timeField, items)
+
+		delete(batches, key)
+		delete(batchBytes, key)
+	}
+
+	flushAll := func() {
+		for key := range batches {
+			flushKey(key)
+		}
+	}
+
+	for {
+		select {
+		case job, ok := <-this.queue:
+			if !ok {
+				flushAll()
+				return
+			}
+
+			itemBytes, err := json.Marshal(job.item)
+			if err != nil {
+				this.config.Logger.Errorw("could not marshal input", "error", err)
+				continue
+			}
+
+			key := batchKey{logType: job.logType, timeField: job.timeField}
+			if len(batches[key]) > 0 && batchBytes[key]+len(itemBytes) > this.config.MaxBatchBytes {
+				flushKey(key)
+			}
+			batches[key] = append(batches[key], job.item)
+			batchBytes[key] += len(itemBytes)
+
+			if len(batches[key]) >= this.config.MaxBatchSize || batchBytes[key] >= this.config.MaxBatchBytes {
+				flushKey(key)
+			}
+		case <-ticker.C:
+			flushAll()
+		}
 	}
 }
 
-func (this *LogAnalytics)Add(item interface{}) {
-	this.queue <- item
+// AddContext enqueues item, routing it to a table and TimeGenerated field via
+// Config.Router (or the transport's default table under
+// DefaultTimeGeneratedField if Router is nil). It applies
+// this.config.FullPolicy if the queue is at capacity, and honors ctx
+// cancellation while it waits for room.
+func (this *LogAnalytics)AddContext(ctx context.Context, item interface{}) error {
+	logType, timeField := "", DefaultTimeGeneratedField
+	if this.config.Router != nil {
+		logType, timeField = this.config.Router(item)
+		if timeField == "" {
+			timeField = DefaultTimeGeneratedField
+		}
+	}
+
+	return this.enqueue(ctx, queuedItem{item: item, logType: logType, timeField: timeField})
+}
+
+// AddToContext is like AddContext but sends item to logType directly,
+// bypassing Config.Router.
+func (this *LogAnalytics)AddToContext(ctx context.Context, logType string, item interface{}) error {
+	return this.enqueue(ctx, queuedItem{item: item, logType: logType, timeField: DefaultTimeGeneratedField})
 }
 
-func (this *LogAnalytics)AddMulti(items []interface{}) {
+// AddTo sends item to logType directly, bypassing Config.Router.
+func (this *LogAnalytics)AddTo(logType string, item interface{}) error {
+	return this.AddToContext(context.Background(), logType, item)
+}
+
+// enqueue holds closeMutex for its duration so Shutdown can't close
+// this.queue while a send into it is in flight; see the closed field.
+func (this *LogAnalytics)enqueue(ctx context.Context, job queuedItem) error {
+	this.closeMutex.RLock()
+	defer this.closeMutex.RUnlock()
+
+	if this.closed {
+		return ErrShutdown
+	}
+
+	switch this.config.FullPolicy {
+	case FullPolicyDropNewest:
+		select {
+		case this.queue <- job:
+			this.config.Metrics.ItemsEnqueued(1)
+			return nil
+		default:
+			return ErrQueueFull
+		}
+	case FullPolicyDropOldest:
+		for {
+			select {
+			case this.queue <- job:
+				this.config.Metrics.ItemsEnqueued(1)
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+				select {
+				case <-this.queue:
+				default:
+				}
+			}
+		}
+	case FullPolicyError:
+		select {
+		case this.queue <- job:
+			this.config.Metrics.ItemsEnqueued(1)
+			return nil
+		default:
+			return ErrQueueFull
+		}
+	default: // FullPolicyBlock
+		select {
+		case this.queue <- job:
+			this.config.Metrics.ItemsEnqueued(1)
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (this *LogAnalytics)AddMultiContext(ctx context.Context, items []interface{}) error {
 	for _, i := range items {
-		this.Add(i)
+		if err := this.AddContext(ctx, i); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+func (this *LogAnalytics)AddMulti(items []interface{}) error {
+	return this.AddMultiContext(context.Background(), items)
 }
 
+// Finalize waits indefinitely for queued items to be sent. Prefer Shutdown
+// for a bounded, cancellable stop.
 func (this* LogAnalytics)Finalize() {
-	log.Print(" [->] Waiting for remaining Log Analytics items...")
+	if err := this.Shutdown(context.Background()); err != nil {
+		this.config.Logger.Errorw("could not cleanly finalize", "error", err)
+	}
+}
+
+// Shutdown stops accepting new work, waits for queued items to flush, and
+// cancels any in-flight send once ctx is done. It returns ctx.Err() if the
+// deadline/cancellation fires before the flush completes. Shutdown and
+// Finalize share this cleanup, so calling either of them more than once (or
+// one after the other) is a no-op past the first call.
+func (this* LogAnalytics)Shutdown(ctx context.Context) error {
+	this.closeMutex.Lock()
+	if this.closed {
+		this.closeMutex.Unlock()
+		return nil
+	}
+	this.closed = true
 	close(this.queue)
-	this.waitGroup.Wait()
-	log.Print(" [->] All Log Analytics items are sent.")
+	this.closeMutex.Unlock()
+
+	this.config.Logger.Infow("waiting for remaining Log Analytics items")
+
+	done := make(chan struct{})
+	go func() {
+		this.waitGroup.Wait()
+		close(done)
+	}()
+
+	var shutdownErr error
+	select {
+	case <-done:
+		this.config.Logger.Infow("all Log Analytics items are sent")
+	case <-ctx.Done():
+		shutdownErr = ctx.Err()
+		this.config.Logger.Warnw("shutdown deadline reached, cancelling in-flight sends", "error", shutdownErr)
+	}
+
+	this.cancel()
+
+	if err := this.config.Spool.Close(); err != nil {
+		this.config.Logger.Errorw("could not close spool", "error", err)
+	}
+
+	return shutdownErr
 }
 
-func (this* LogAnalytics)send(item interface{}) {
-	dateString := time.Now().UTC().Format(signatureDateFormat)
-	dateString = strings.Replace(dateString, "UTC", "GMT", -1)
-	body, err := json.Marshal(item)
+// sendError carries whether a failed send is worth retrying, and how long to
+// wait before the next attempt if the server told us (Retry-After).
+type sendError struct {
+	retryable  bool
+	retryAfter time.Duration
+	err        error
+}
+
+func (this *sendError)Error() string {
+	return this.err.Error()
+}
+
+// sendWithRetry sends items, retrying transient failures (network errors,
+// 429 and 5xx responses) with jittered exponential backoff honoring any
+// Retry-After the server sent, and acks batchID in the spool once the batch
+// is delivered or permanently given up on.
+func (this* LogAnalytics)sendWithRetry(batchID uint64, logType string, timeField string, items []interface{}) {
+	delay := this.config.BaseRetryDelay
+
+	for attempt := 0; ; attempt++ {
+		sendErr := this.send(logType, timeField, items)
+		if sendErr == nil {
+			if err := this.config.Spool.Ack(batchID); err != nil {
+				this.config.Logger.Errorw("could not ack spooled batch", "batchId", batchID, "error", err)
+			}
+			return
+		}
+
+		this.config.Logger.Warnw("send failed", "batchId", batchID, "attempt", attempt+1, "error", sendErr)
+
+		if !sendErr.retryable || attempt >= this.config.MaxRetries {
+			this.config.Logger.Errorw("giving up on batch", "batchId", batchID, "attempts", attempt+1)
+			if err := this.config.Spool.Ack(batchID); err != nil {
+				this.config.Logger.Errorw("could not ack spooled batch", "batchId", batchID, "error", err)
+			}
+			return
+		}
+
+		this.config.Metrics.RetryAttempted()
+
+		wait := delay
+		if sendErr.retryAfter > 0 {
+			wait = sendErr.retryAfter
+		}
+
+		timer := time.NewTimer(withJitter(wait))
+		select {
+		case <-timer.C:
+		case <-this.ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		delay *= 2
+		if delay > this.config.MaxRetryDelay {
+			delay = this.config.MaxRetryDelay
+		}
+	}
+}
+
+func (this* LogAnalytics)send(logType string, timeField string, items []interface{}) *sendError {
+	if len(items) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(items)
 	if err != nil {
-		log.Print("[LogAnalytics]: Could not marshal input: ", err.Error())
-		return
+		return &sendError{err: fmt.Errorf("could not marshal batch: %w", err)}
 	}
 
-	req, err := http.NewRequest("POST", this.url, bytes.NewReader(body))
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Log-Type", this.logName)
-	req.Header.Set("Authorization", this.generateAuthorization("POST", len(body), dateString))
-	req.Header.Set("x-ms-date", dateString)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("time-generated-field", "TimeGenerated")
+	// The signature is always computed over the uncompressed body length, per
+	// Azure's HTTP Data Collector rules, even when the wire payload is gzip'd.
+	contentLength := len(body)
+	payload := body
+	gzipped := false
+	if contentLength > this.config.GzipThresholdBytes {
+		compressed, err := gzipCompress(body)
+		if err != nil {
+			this.config.Logger.Warnw("could not gzip batch, sending uncompressed", "error", err)
+		} else {
+			payload = compressed
+			gzipped = true
+		}
+	}
 
+	req, err := this.transport.buildRequest(this.ctx, payload, contentLength, gzipped, logType, timeField)
 	if err != nil {
-		log.Print("[LogAnalytics]: Could create http request: ", err.Error())
-		return
+		return &sendError{retryable: true, err: fmt.Errorf("could not create http request: %w", err)}
 	}
 
+	start := time.Now()
 	resp, err := this.httpClient.Do(req)
+	this.config.Metrics.SendLatency(time.Since(start))
 	if err != nil {
-		log.Print("[LogAnalytics]: Could create send http request: ", err.Error())
-		return
+		return &sendError{retryable: true, err: fmt.Errorf("could not send http request: %w", err)}
 	}
 	defer resp.Body.Close()
 
+	this.config.Metrics.HTTPStatus(resp.StatusCode)
+
 	if resp.StatusCode >= 300 {
-		responseBody, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Print("[LogAnalytics]: Could insert log item and could not read response body: ", err.Error())
-			return
+		responseBody, readErr := ioutil.ReadAll(resp.Body)
+		if readErr != nil {
+			return &sendError{retryable: true, err: fmt.Errorf("could not insert log batch (%d) and could not read response body: %w", resp.StatusCode, readErr)}
+		}
+		return &sendError{
+			retryable:  isRetryableStatus(resp.StatusCode),
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			err:        fmt.Errorf("could not insert log batch (%d): %s", resp.StatusCode, string(responseBody)),
 		}
-		log.Print("[LogAnalytics]: Could not insert log item (", strconv.Itoa(resp.StatusCode), "): ", string(responseBody))
-		return
 	}
 
-	return
+	this.config.Metrics.BatchesSent(1)
+	this.config.Metrics.BytesSent(contentLength)
+
+	return nil
+}
+
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
 }
 
-func (this* LogAnalytics)generateAuthorization(method string, bodyLength int, dateString string) string {
-	stringToHash := method + "\n" + strconv.Itoa(bodyLength) + "\napplication/json\n" + "x-ms-date:" + dateString + "\n/api/logs"
-	return "SharedKey " + this.workspaceId + ":" + this.buildSignature(stringToHash)
+// parseRetryAfter understands both forms Azure (and HTTP in general) sends
+// Retry-After in: a number of seconds, or an HTTP-date.
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := time.Parse(time.RFC1123, header); err == nil {
+		if wait := time.Until(when); wait > 0 {
+			return wait
+		}
+	}
+	return 0
 }
 
-func (this *LogAnalytics)buildSignature(message string) string {
-	h := hmac.New(sha256.New, []byte(this.sharedKey))
-	h.Write([]byte(message))
-	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+func withJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
 }
 
+func gzipCompress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
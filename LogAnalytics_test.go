@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// TestBuildRequestSignsUncompressedLength guards classicTransport's side of
+// the invariant in send() (LogAnalytics.go) that a gzip'd batch is still
+// signed over its *uncompressed* length: Azure's HTTP Data Collector API
+// requires the Content-Length used in the signature to match the wire body
+// before compression. It calls buildRequest directly, so it checks that the
+// signature uses the contentLength passed in rather than the gzip'd
+// payload's length; send() itself is not exercised here.
+func TestBuildRequestSignsUncompressedLength(t *testing.T) {
+	uncompressed := make([]byte, DefaultGzipThresholdBytes*2)
+	for i := range uncompressed {
+		uncompressed[i] = byte(i % 251)
+	}
+
+	compressed, err := gzipCompress(uncompressed)
+	if err != nil {
+		t.Fatalf("gzipCompress: %v", err)
+	}
+	if len(compressed) >= len(uncompressed) {
+		t.Fatalf("expected gzip to shrink a repetitive payload, got %d >= %d", len(compressed), len(uncompressed))
+	}
+
+	transport := &classicTransport{
+		workspaceId: "ws",
+		sharedKey:   []byte("key"),
+		logName:     "Test",
+		url:         "https://example.invalid",
+	}
+
+	req, err := transport.buildRequest(context.Background(), compressed, len(uncompressed), true, "", DefaultTimeGeneratedField)
+	if err != nil {
+		t.Fatalf("buildRequest: %v", err)
+	}
+
+	want := transport.generateAuthorization("POST", len(uncompressed), req.Header.Get("x-ms-date"))
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("Authorization signed over wrong length: got %q want %q (uncompressed=%d compressed=%d)", got, want, len(uncompressed), len(compressed))
+	}
+}
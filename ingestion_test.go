@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type fakeTokenProvider struct {
+	token string
+	err   error
+}
+
+func (this fakeTokenProvider) Token(ctx context.Context) (string, error) {
+	return this.token, this.err
+}
+
+// TestAadIngestionTransportBuildRequest guards the request shape
+// aadIngestionTransport builds against the Logs Ingestion API: the DCR/stream
+// URL, the bearer token in Authorization, and gzip signalling, none of which
+// had any test coverage.
+func TestAadIngestionTransportBuildRequest(t *testing.T) {
+	transport := &aadIngestionTransport{
+		endpoint:       "https://my-dce.westeurope-1.ingest.monitor.azure.com",
+		dcrImmutableId: "dcr-0123456789",
+		streamName:     "Custom-AppEvents",
+		cred:           fakeTokenProvider{token: "test-token"},
+	}
+
+	req, err := transport.buildRequest(context.Background(), []byte(`[{"a":1}]`), 9, true, "", "TimeGenerated")
+	if err != nil {
+		t.Fatalf("buildRequest: %v", err)
+	}
+
+	wantURL := "https://my-dce.westeurope-1.ingest.monitor.azure.com/dataCollectionRules/dcr-0123456789/streams/Custom-AppEvents?api-version=2023-01-01"
+	if got := req.URL.String(); got != wantURL {
+		t.Fatalf("URL = %q, want %q", got, wantURL)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer test-token" {
+		t.Fatalf("Authorization = %q, want %q", got, "Bearer test-token")
+	}
+	if got := req.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+}
+
+// TestAadIngestionTransportBuildRequestRoutesLogTypeToStream guards that a
+// non-empty logType (as set by AddTo/Router) overrides the transport's
+// default stream, the same way classicTransport's logType overrides logName.
+func TestAadIngestionTransportBuildRequestRoutesLogTypeToStream(t *testing.T) {
+	transport := &aadIngestionTransport{
+		endpoint:       "https://my-dce.westeurope-1.ingest.monitor.azure.com",
+		dcrImmutableId: "dcr-0123456789",
+		streamName:     "Custom-AppEvents",
+		cred:           fakeTokenProvider{token: "test-token"},
+	}
+
+	req, err := transport.buildRequest(context.Background(), []byte(`[]`), 2, false, "Custom-AppErrors", "TimeGenerated")
+	if err != nil {
+		t.Fatalf("buildRequest: %v", err)
+	}
+
+	if !strings.Contains(req.URL.String(), "/streams/Custom-AppErrors?") {
+		t.Fatalf("URL = %q, want stream overridden to Custom-AppErrors", req.URL.String())
+	}
+	if got := req.Header.Get("Content-Encoding"); got != "" {
+		t.Fatalf("Content-Encoding = %q, want unset for an uncompressed payload", got)
+	}
+}
+
+// TestAadIngestionTransportBuildRequestWrapsTokenError guards that a
+// TokenProvider failure surfaces as a buildRequest error instead of being
+// sent on with an empty/invalid Authorization header.
+func TestAadIngestionTransportBuildRequestWrapsTokenError(t *testing.T) {
+	tokenErr := errors.New("aad: token request failed")
+	transport := &aadIngestionTransport{
+		endpoint:       "https://my-dce.westeurope-1.ingest.monitor.azure.com",
+		dcrImmutableId: "dcr-0123456789",
+		streamName:     "Custom-AppEvents",
+		cred:           fakeTokenProvider{err: tokenErr},
+	}
+
+	if _, err := transport.buildRequest(context.Background(), []byte(`[]`), 2, false, "", "TimeGenerated"); !errors.Is(err, tokenErr) {
+		t.Fatalf("buildRequest error = %v, want it to wrap %v", err, tokenErr)
+	}
+}
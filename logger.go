@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger is the structured logging interface LogAnalytics reports through,
+// matching the fields-style API common to logrus' and zap's sugared loggers
+// so either can be adapted with a thin wrapper.
+type Logger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+// stdLogger is the default Logger, used when Config.Logger is nil. It writes
+// through the standard library logger so behavior is unchanged for callers
+// who don't plug in their own.
+type stdLogger struct{}
+
+func (stdLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	stdLogger{}.log("DEBUG", msg, keysAndValues)
+}
+
+func (stdLogger) Infow(msg string, keysAndValues ...interface{}) {
+	stdLogger{}.log("INFO", msg, keysAndValues)
+}
+
+func (stdLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	stdLogger{}.log("WARN", msg, keysAndValues)
+}
+
+func (stdLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	stdLogger{}.log("ERROR", msg, keysAndValues)
+}
+
+func (stdLogger) log(level string, msg string, keysAndValues []interface{}) {
+	log.Print("[LogAnalytics] " + level + ": " + msg + formatFields(keysAndValues))
+}
+
+// formatFields renders keysAndValues as " key=value" pairs, e.g.
+// [" batchId=5 error=boom"], so each pair stays readable instead of being
+// splatted into fmt.Print, which drops the separator between any two
+// operands when one of them is a string.
+func formatFields(keysAndValues []interface{}) string {
+	if len(keysAndValues) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := keysAndValues[i]
+		builder.WriteString(" ")
+		builder.WriteString(fmt.Sprint(key))
+		builder.WriteString("=")
+		if i+1 < len(keysAndValues) {
+			builder.WriteString(fmt.Sprint(keysAndValues[i+1]))
+		} else {
+			builder.WriteString("(MISSING)")
+		}
+	}
+	return builder.String()
+}
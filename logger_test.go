@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+)
+
+// TestStdLoggerRendersReadableKeyValuePairs guards stdLogger.log against
+// splatting keysAndValues into fmt.Print, which drops the separator between
+// any two operands when one of them is a string (e.g. a string msg right
+// next to a string key, or a key right next to its value) and ran every
+// Warnw/Errorw call together into one illegible word.
+func TestStdLoggerRendersReadableKeyValuePairs(t *testing.T) {
+	var buf bytes.Buffer
+	original := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(original)
+	originalFlags := log.Flags()
+	log.SetFlags(0)
+	defer log.SetFlags(originalFlags)
+
+	stdLogger{}.Errorw("could not ack spooled batch", "batchId", uint64(5), "error", errors.New("boom"))
+
+	got := buf.String()
+	for _, want := range []string{"could not ack spooled batch", "batchId=5", "error=boom"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("log output %q missing %q", got, want)
+		}
+	}
+	if strings.Contains(got, "batchId5") || strings.Contains(got, "batch5") {
+		t.Fatalf("log output %q ran fields together with no separator", got)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "time"
+
+// Metrics receives counters/histograms for what the collector is doing, so
+// operators can wire it into their own dashboards and alerting instead of
+// having to scrape this package's logs. Implementations only need to match
+// this method set; see metrics/prometheus for a ready-made one.
+type Metrics interface {
+	ItemsEnqueued(n int)
+	BatchesSent(n int)
+	BytesSent(n int)
+	HTTPStatus(statusCode int)
+	RetryAttempted()
+	SendLatency(d time.Duration)
+}
+
+// noopMetrics is the default Metrics, used when Config.Metrics is nil.
+type noopMetrics struct{}
+
+func (noopMetrics) ItemsEnqueued(n int)         {}
+func (noopMetrics) BatchesSent(n int)           {}
+func (noopMetrics) BytesSent(n int)             {}
+func (noopMetrics) HTTPStatus(statusCode int)   {}
+func (noopMetrics) RetryAttempted()             {}
+func (noopMetrics) SendLatency(d time.Duration) {}
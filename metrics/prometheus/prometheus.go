@@ -0,0 +1,96 @@
+// Package prometheus implements the collector's Metrics interface on top of
+// github.com/prometheus/client_golang, so items enqueued, batches sent, bytes
+// sent, HTTP status classes, retry counts and end-to-end send latency can be
+// scraped like any other Prometheus target.
+package prometheus
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics implements the collector's Metrics interface. Construct it with
+// New and pass it in as Config.Metrics; it satisfies that interface
+// structurally, so this package never imports the collector itself.
+type Metrics struct {
+	itemsEnqueued prometheus.Counter
+	batchesSent   prometheus.Counter
+	bytesSent     prometheus.Counter
+	httpStatus    *prometheus.CounterVec
+	retries       prometheus.Counter
+	sendLatency   prometheus.Histogram
+}
+
+// New creates a Metrics and registers its collectors against reg.
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		itemsEnqueued: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "loganalytics",
+			Name:      "items_enqueued_total",
+			Help:      "Number of items accepted into the queue by AddContext/AddTo.",
+		}),
+		batchesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "loganalytics",
+			Name:      "batches_sent_total",
+			Help:      "Number of batches successfully POSTed to Azure Monitor.",
+		}),
+		bytesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "loganalytics",
+			Name:      "bytes_sent_total",
+			Help:      "Uncompressed JSON bytes successfully POSTed to Azure Monitor.",
+		}),
+		httpStatus: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loganalytics",
+			Name:      "http_responses_total",
+			Help:      "HTTP responses received, labeled by status class.",
+		}, []string{"class"}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "loganalytics",
+			Name:      "retries_total",
+			Help:      "Number of retried batch sends.",
+		}),
+		sendLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "loganalytics",
+			Name:      "send_latency_seconds",
+			Help:      "End-to-end latency of a single POST attempt.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+	}
+
+	reg.MustRegister(m.itemsEnqueued, m.batchesSent, m.bytesSent, m.httpStatus, m.retries, m.sendLatency)
+
+	return m
+}
+
+func (this *Metrics)ItemsEnqueued(n int) {
+	this.itemsEnqueued.Add(float64(n))
+}
+
+func (this *Metrics)BatchesSent(n int) {
+	this.batchesSent.Add(float64(n))
+}
+
+func (this *Metrics)BytesSent(n int) {
+	this.bytesSent.Add(float64(n))
+}
+
+func (this *Metrics)HTTPStatus(statusCode int) {
+	this.httpStatus.WithLabelValues(statusClass(statusCode)).Inc()
+}
+
+func (this *Metrics)RetryAttempted() {
+	this.retries.Inc()
+}
+
+func (this *Metrics)SendLatency(d time.Duration) {
+	this.sendLatency.Observe(d.Seconds())
+}
+
+func statusClass(statusCode int) string {
+	if statusCode < 100 || statusCode > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(statusCode/100) + "xx"
+}
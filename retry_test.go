@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSendWithRetryRecoversFromTransientFailures guards sendWithRetry's
+// backoff loop (LogAnalytics.go): a batch that fails with a retryable status
+// must be retried until it succeeds instead of being dropped after the first
+// failure, which is what made the original single-POST-per-item client lose
+// data on any transient error.
+func TestSendWithRetryRecoversFromTransientFailures(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport := &classicTransport{workspaceId: "ws", sharedKey: []byte("key"), logName: "Test", url: server.URL}
+
+	config := defaultConfig()
+	config.BaseRetryDelay = time.Millisecond
+	config.MaxRetryDelay = 5 * time.Millisecond
+	config.FlushInterval = 10 * time.Millisecond
+
+	la := newLogAnalytics(transport, config)
+	// Let the worker goroutines reach their select loop before adding work;
+	// newLogAnalytics has already registered them with the waitGroup.
+	time.Sleep(20 * time.Millisecond)
+
+	if err := la.AddContext(context.Background(), map[string]interface{}{"a": 1}); err != nil {
+		t.Fatalf("AddContext: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := la.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Fatalf("expected 3 attempts (2 failures + 1 success), got %d", got)
+	}
+}
+
+// TestSendWithRetryHonorsRetryAfter guards parseRetryAfter/sendWithRetry's
+// wait-before-next-attempt logic: a 429 carrying Retry-After must delay the
+// next attempt by (roughly) that long rather than the much shorter base
+// backoff delay.
+func TestSendWithRetryHonorsRetryAfter(t *testing.T) {
+	var attempts int32
+	var firstAttempt, secondAttempt time.Time
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			firstAttempt = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		secondAttempt = time.Now()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport := &classicTransport{workspaceId: "ws", sharedKey: []byte("key"), logName: "Test", url: server.URL}
+
+	config := defaultConfig()
+	config.BaseRetryDelay = time.Millisecond
+	config.MaxRetryDelay = time.Millisecond
+	config.FlushInterval = 10 * time.Millisecond
+
+	la := newLogAnalytics(transport, config)
+	time.Sleep(20 * time.Millisecond)
+
+	if err := la.AddContext(context.Background(), map[string]interface{}{"a": 1}); err != nil {
+		t.Fatalf("AddContext: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := la.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&attempts); got != 2 {
+		t.Fatalf("expected 2 attempts, got %d", got)
+	}
+	// withJitter halves Retry-After and adds up to another half, so the wait
+	// lands in [500ms, 1s]; BaseRetryDelay is 1ms, so anything well above
+	// that confirms Retry-After won out over the base backoff.
+	if gap := secondAttempt.Sub(firstAttempt); gap < 300*time.Millisecond {
+		t.Fatalf("expected retry to wait out the Retry-After header, only waited %s", gap)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type capturedRequest struct {
+	logType   string
+	timeField string
+	body      string
+}
+
+// TestWorkerGroupsByLogTypeAndTimeField guards the worker's batchKey grouping
+// (LogAnalytics.go): items routed to different (logType, timeField) pairs via
+// AddContext/Config.Router and AddTo must be dispatched as separate POSTs,
+// each carrying only its own table's items and headers, rather than being
+// merged into one batch under whichever table happened to flush first.
+func TestWorkerGroupsByLogTypeAndTimeField(t *testing.T) {
+	var mutex sync.Mutex
+	var requests []capturedRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mutex.Lock()
+		requests = append(requests, capturedRequest{
+			logType:   r.Header.Get("Log-Type"),
+			timeField: r.Header.Get("time-generated-field"),
+			body:      string(body),
+		})
+		mutex.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport := &classicTransport{workspaceId: "ws", sharedKey: []byte("key"), logName: "Default", url: server.URL}
+
+	config := defaultConfig()
+	config.FlushInterval = 10 * time.Millisecond
+	config.Router = func(item interface{}) (string, string) {
+		m := item.(map[string]interface{})
+		return m["table"].(string), m["stamp"].(string)
+	}
+
+	la := newLogAnalytics(transport, config)
+	time.Sleep(20 * time.Millisecond)
+
+	if err := la.AddContext(context.Background(), map[string]interface{}{"table": "AppEvents_CL", "stamp": "TimeGenerated", "v": 1}); err != nil {
+		t.Fatalf("AddContext: %v", err)
+	}
+	if err := la.AddTo("AppErrors_CL", map[string]interface{}{"v": 2}); err != nil {
+		t.Fatalf("AddTo: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := la.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 separate POSTs (one per table), got %d: %+v", len(requests), requests)
+	}
+
+	byLogType := make(map[string]capturedRequest, len(requests))
+	for _, req := range requests {
+		byLogType[req.logType] = req
+	}
+
+	events, ok := byLogType["AppEvents_CL"]
+	if !ok {
+		t.Fatalf("missing AppEvents_CL request: %+v", requests)
+	}
+	if events.timeField != "TimeGenerated" {
+		t.Fatalf("AppEvents_CL time-generated-field = %q, want %q", events.timeField, "TimeGenerated")
+	}
+	if !strings.Contains(events.body, `"v":1`) {
+		t.Fatalf("AppEvents_CL body missing its own item: %s", events.body)
+	}
+	if strings.Contains(events.body, `"v":2`) {
+		t.Fatalf("AppEvents_CL body leaked AppErrors_CL's item: %s", events.body)
+	}
+
+	errorsReq, ok := byLogType["AppErrors_CL"]
+	if !ok {
+		t.Fatalf("missing AppErrors_CL request: %+v", requests)
+	}
+	if errorsReq.timeField != DefaultTimeGeneratedField {
+		t.Fatalf("AppErrors_CL time-generated-field = %q, want %q", errorsReq.timeField, DefaultTimeGeneratedField)
+	}
+	if !strings.Contains(errorsReq.body, `"v":2`) {
+		t.Fatalf("AppErrors_CL body missing its own item: %s", errorsReq.body)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestShutdownIsIdempotent guards the fix making Shutdown a no-op past the
+// first call: calling it twice, or calling Finalize after Shutdown, used to
+// panic with "close of closed channel".
+func TestShutdownIsIdempotent(t *testing.T) {
+	config := defaultConfig()
+	la := newLogAnalytics(&classicTransport{workspaceId: "ws", sharedKey: []byte("key"), logName: "Test", url: "https://example.invalid"}, config)
+
+	if err := la.Shutdown(context.Background()); err != nil {
+		t.Fatalf("first Shutdown: %v", err)
+	}
+	if err := la.Shutdown(context.Background()); err != nil {
+		t.Fatalf("second Shutdown: %v", err)
+	}
+	// Finalize is documented as sharing Shutdown's cleanup; it must not panic
+	// either once Shutdown has already run.
+	la.Finalize()
+}
+
+// TestAddContextAfterShutdownReturnsErrShutdown guards that enqueue rejects
+// new work once the queue has been closed instead of panicking on a send to
+// a closed channel.
+func TestAddContextAfterShutdownReturnsErrShutdown(t *testing.T) {
+	config := defaultConfig()
+	la := newLogAnalytics(&classicTransport{workspaceId: "ws", sharedKey: []byte("key"), logName: "Test", url: "https://example.invalid"}, config)
+
+	if err := la.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if err := la.AddContext(context.Background(), map[string]interface{}{"a": 1}); !errors.Is(err, ErrShutdown) {
+		t.Fatalf("AddContext after Shutdown = %v, want ErrShutdown", err)
+	}
+}
+
+// TestEnqueueFullPolicies guards each FullPolicy's behavior against a full
+// queue, exercising enqueue directly (bypassing the worker) so the queue
+// stays reliably full for the duration of each case.
+func TestEnqueueFullPolicies(t *testing.T) {
+	newFullQueue := func(policy FullPolicy) *LogAnalytics {
+		la := &LogAnalytics{
+			queue:  make(chan queuedItem, 1),
+			config: Config{FullPolicy: policy, Metrics: noopMetrics{}},
+		}
+		la.queue <- queuedItem{item: "already queued"}
+		return la
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		la := newFullQueue(FullPolicyError)
+		if err := la.enqueue(context.Background(), queuedItem{item: "new"}); !errors.Is(err, ErrQueueFull) {
+			t.Fatalf("enqueue = %v, want ErrQueueFull", err)
+		}
+	})
+
+	t.Run("DropNewest", func(t *testing.T) {
+		la := newFullQueue(FullPolicyDropNewest)
+		if err := la.enqueue(context.Background(), queuedItem{item: "new"}); !errors.Is(err, ErrQueueFull) {
+			t.Fatalf("enqueue = %v, want ErrQueueFull", err)
+		}
+		if got := (<-la.queue).item; got != "already queued" {
+			t.Fatalf("DropNewest should have kept the original item, got %v", got)
+		}
+	})
+
+	t.Run("DropOldestMakesRoom", func(t *testing.T) {
+		la := newFullQueue(FullPolicyDropOldest)
+		if err := la.enqueue(context.Background(), queuedItem{item: "new"}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		if got := (<-la.queue).item; got != "new" {
+			t.Fatalf("DropOldest should have evicted the old item to make room, got %v", got)
+		}
+	})
+
+	t.Run("DropOldestHonorsCtxCancellation", func(t *testing.T) {
+		// The queue is full and ctx is already cancelled, so ctx.Done() is the
+		// only ready case: enqueue must return ctx.Err() instead of evicting
+		// the queued item to make room.
+		la := newFullQueue(FullPolicyDropOldest)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := la.enqueue(ctx, queuedItem{item: "new"}); !errors.Is(err, context.Canceled) {
+			t.Fatalf("enqueue = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("BlockHonorsCtxCancellation", func(t *testing.T) {
+		la := newFullQueue(FullPolicyBlock)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := la.enqueue(ctx, queuedItem{item: "new"}); !errors.Is(err, context.Canceled) {
+			t.Fatalf("enqueue = %v, want context.Canceled", err)
+		}
+	})
+}
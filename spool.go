@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// SpooledBatch is a batch as persisted by a Spool: the items plus the table
+// (LogType) and TimeGenerated field (TimeField) they must be resent under, so
+// a replayed batch after a restart still lands in the right table.
+type SpooledBatch struct {
+	LogType   string        `json:"logType"`
+	TimeField string        `json:"timeField"`
+	Items     []interface{} `json:"items"`
+}
+
+// Spool persists batches that have been handed to send() but not yet
+// acknowledged, so they can be replayed after a process restart if the
+// process dies before delivery completes.
+type Spool interface {
+	// Write durably records a pending batch under batchID.
+	Write(batchID uint64, batch SpooledBatch) error
+	// Ack marks batchID as delivered; the spool may discard it.
+	Ack(batchID uint64) error
+	// Pending returns every batch that was written but never acked.
+	Pending() (map[uint64]SpooledBatch, error)
+	// Close releases any resources held by the spool.
+	Close() error
+}
+
+// MemorySpool is the default Spool. It keeps pending batches in memory only,
+// so anything still unacked is lost on process restart.
+type MemorySpool struct {
+	mutex   sync.Mutex
+	pending map[uint64]SpooledBatch
+}
+
+func NewMemorySpool() *MemorySpool {
+	return &MemorySpool{
+		pending: make(map[uint64]SpooledBatch),
+	}
+}
+
+func (this *MemorySpool)Write(batchID uint64, batch SpooledBatch) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.pending[batchID] = batch
+	return nil
+}
+
+func (this *MemorySpool)Ack(batchID uint64) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	delete(this.pending, batchID)
+	return nil
+}
+
+func (this *MemorySpool)Pending() (map[uint64]SpooledBatch, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	pending := make(map[uint64]SpooledBatch, len(this.pending))
+	for id, batch := range this.pending {
+		pending[id] = batch
+	}
+	return pending, nil
+}
+
+func (this *MemorySpool)Close() error {
+	return nil
+}
+
+type spoolRecord struct {
+	ID uint64 `json:"id"`
+	SpooledBatch
+}
+
+// DefaultSpoolCompactThreshold is how many batches FileSpool will Ack before
+// it rewrites its segment file to drop them, so a long-running collector's
+// spool directory doesn't grow by one line per batch/ack forever.
+const DefaultSpoolCompactThreshold int = 1000
+
+// FileSpool persists pending batches to an append-only segment file so they
+// survive process restarts. Acknowledged batch ids are appended to a
+// companion index file; Pending() replays the segment and drops whatever ids
+// already show up in the index. Once compactThreshold acks have accumulated,
+// Ack rewrites the segment to only the batches still pending and truncates
+// the index, bounding both files' size for a long-running collector.
+type FileSpool struct {
+	mutex            sync.Mutex
+	dir              string
+	segment          *os.File
+	ackIndex         *os.File
+	ackCount         int
+	compactThreshold int
+}
+
+func NewFileSpool(dir string) (*FileSpool, error) {
+	return NewFileSpoolWithCompactThreshold(dir, DefaultSpoolCompactThreshold)
+}
+
+// NewFileSpoolWithCompactThreshold is like NewFileSpool but allows tuning how
+// many acks accumulate before the segment is compacted; a threshold <= 0
+// disables compaction.
+func NewFileSpoolWithCompactThreshold(dir string, compactThreshold int) (*FileSpool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	segment, err := os.OpenFile(filepath.Join(dir, "spool.log"), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	ackIndex, err := os.OpenFile(filepath.Join(dir, "spool.ack"), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		segment.Close()
+		return nil, err
+	}
+
+	return &FileSpool{dir: dir, segment: segment, ackIndex: ackIndex, compactThreshold: compactThreshold}, nil
+}
+
+func (this *FileSpool)Write(batchID uint64, batch SpooledBatch) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	record, err := json.Marshal(spoolRecord{ID: batchID, SpooledBatch: batch})
+	if err != nil {
+		return err
+	}
+
+	if _, err := this.segment.Write(append(record, '\n')); err != nil {
+		return err
+	}
+	return this.segment.Sync()
+}
+
+func (this *FileSpool)Ack(batchID uint64) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if _, err := fmt.Fprintln(this.ackIndex, batchID); err != nil {
+		return err
+	}
+	if err := this.ackIndex.Sync(); err != nil {
+		return err
+	}
+
+	this.ackCount++
+	if this.compactThreshold <= 0 || this.ackCount < this.compactThreshold {
+		return nil
+	}
+	return this.compactLocked()
+}
+
+// compactLocked rewrites the segment file to hold only the batches still
+// pending and truncates the ack index, dropping everything compaction made
+// redundant. Callers must hold this.mutex.
+func (this *FileSpool)compactLocked() error {
+	pending, err := this.pendingLocked()
+	if err != nil {
+		return err
+	}
+
+	tmpSegment, err := os.CreateTemp(this.dir, "spool.log.compact-*")
+	if err != nil {
+		return err
+	}
+	for id, batch := range pending {
+		record, err := json.Marshal(spoolRecord{ID: id, SpooledBatch: batch})
+		if err != nil {
+			tmpSegment.Close()
+			os.Remove(tmpSegment.Name())
+			return err
+		}
+		if _, err := tmpSegment.Write(append(record, '\n')); err != nil {
+			tmpSegment.Close()
+			os.Remove(tmpSegment.Name())
+			return err
+		}
+	}
+	if err := tmpSegment.Sync(); err != nil {
+		tmpSegment.Close()
+		os.Remove(tmpSegment.Name())
+		return err
+	}
+	if err := tmpSegment.Close(); err != nil {
+		os.Remove(tmpSegment.Name())
+		return err
+	}
+
+	segmentPath := this.segment.Name()
+	if err := this.segment.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpSegment.Name(), segmentPath); err != nil {
+		return err
+	}
+	segment, err := os.OpenFile(segmentPath, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	this.segment = segment
+
+	ackPath := this.ackIndex.Name()
+	if err := this.ackIndex.Close(); err != nil {
+		return err
+	}
+	if err := os.Truncate(ackPath, 0); err != nil {
+		return err
+	}
+	ackIndex, err := os.OpenFile(ackPath, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	this.ackIndex = ackIndex
+
+	this.ackCount = 0
+	return nil
+}
+
+func (this *FileSpool)Pending() (map[uint64]SpooledBatch, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.pendingLocked()
+}
+
+// pendingLocked is Pending's body, usable by compactLocked without
+// re-acquiring this.mutex. Callers must hold this.mutex.
+func (this *FileSpool)pendingLocked() (map[uint64]SpooledBatch, error) {
+	acked, err := this.readAckedIds()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := this.segment.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	pending := make(map[uint64]SpooledBatch)
+	scanner := bufio.NewScanner(this.segment)
+	for scanner.Scan() {
+		var record spoolRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if acked[record.ID] {
+			delete(pending, record.ID)
+			continue
+		}
+		pending[record.ID] = record.SpooledBatch
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := this.segment.Seek(0, 2); err != nil {
+		return nil, err
+	}
+
+	return pending, nil
+}
+
+func (this *FileSpool)readAckedIds() (map[uint64]bool, error) {
+	if _, err := this.ackIndex.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	acked := make(map[uint64]bool)
+	scanner := bufio.NewScanner(this.ackIndex)
+	for scanner.Scan() {
+		var id uint64
+		if _, err := fmt.Sscan(scanner.Text(), &id); err == nil {
+			acked[id] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := this.ackIndex.Seek(0, 2); err != nil {
+		return nil, err
+	}
+
+	return acked, nil
+}
+
+func (this *FileSpool)Close() error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	segErr := this.segment.Close()
+	ackErr := this.ackIndex.Close()
+	if segErr != nil {
+		return segErr
+	}
+	return ackErr
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFileSpoolRoundTrip guards Write/Ack/Pending against dropping or
+// corrupting a batch's routing metadata (LogType/TimeField), which
+// replayPending relies on to resend a batch to the right table after a
+// crash.
+func TestFileSpoolRoundTrip(t *testing.T) {
+	spool, err := NewFileSpool(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileSpool: %v", err)
+	}
+	defer spool.Close()
+
+	batch1 := SpooledBatch{LogType: "Foo", TimeField: "TimeGenerated", Items: []interface{}{map[string]interface{}{"a": float64(1)}}}
+	batch2 := SpooledBatch{LogType: "Bar", TimeField: "Stamp", Items: []interface{}{map[string]interface{}{"b": float64(2)}}}
+
+	if err := spool.Write(1, batch1); err != nil {
+		t.Fatalf("Write(1): %v", err)
+	}
+	if err := spool.Write(2, batch2); err != nil {
+		t.Fatalf("Write(2): %v", err)
+	}
+
+	pending, err := spool.Pending()
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("expected 2 pending batches, got %d", len(pending))
+	}
+
+	if err := spool.Ack(1); err != nil {
+		t.Fatalf("Ack(1): %v", err)
+	}
+
+	pending, err = spool.Pending()
+	if err != nil {
+		t.Fatalf("Pending after ack: %v", err)
+	}
+	if _, ok := pending[1]; ok {
+		t.Fatalf("batch 1 should have been acked")
+	}
+	got, ok := pending[2]
+	if !ok {
+		t.Fatalf("batch 2 should still be pending")
+	}
+	if got.LogType != batch2.LogType || got.TimeField != batch2.TimeField {
+		t.Fatalf("batch 2 round-tripped wrong: got %+v want %+v", got, batch2)
+	}
+}
+
+// TestFileSpoolSurvivesReopen guards the durability guarantee FileSpool
+// exists for: an unacked batch must still be pending after the process
+// restarts and reopens the same directory.
+func TestFileSpoolSurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	spool, err := NewFileSpool(dir)
+	if err != nil {
+		t.Fatalf("NewFileSpool: %v", err)
+	}
+	batch := SpooledBatch{LogType: "Foo", TimeField: "TimeGenerated", Items: []interface{}{map[string]interface{}{"a": float64(1)}}}
+	if err := spool.Write(1, batch); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := spool.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := NewFileSpool(dir)
+	if err != nil {
+		t.Fatalf("NewFileSpool (reopen): %v", err)
+	}
+	defer reopened.Close()
+
+	pending, err := reopened.Pending()
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("expected batch to survive process restart, got %d pending", len(pending))
+	}
+}
+
+// TestFileSpoolCompacts guards the compaction Ack triggers once
+// compactThreshold acks accumulate: it must shrink the segment file down to
+// only the still-pending batches instead of letting it grow by one line per
+// batch/ack forever, while still leaving replay/Pending correct afterward.
+func TestFileSpoolCompacts(t *testing.T) {
+	dir := t.TempDir()
+
+	spool, err := NewFileSpoolWithCompactThreshold(dir, 3)
+	if err != nil {
+		t.Fatalf("NewFileSpoolWithCompactThreshold: %v", err)
+	}
+	defer spool.Close()
+
+	for id := uint64(1); id <= 3; id++ {
+		batch := SpooledBatch{LogType: "Foo", TimeField: "TimeGenerated", Items: []interface{}{map[string]interface{}{"id": float64(id)}}}
+		if err := spool.Write(id, batch); err != nil {
+			t.Fatalf("Write(%d): %v", id, err)
+		}
+	}
+
+	segmentPath := filepath.Join(dir, "spool.log")
+	sizeBeforeCompaction, err := fileSize(segmentPath)
+	if err != nil {
+		t.Fatalf("fileSize before compaction: %v", err)
+	}
+
+	// Acking 1 and 2 leaves batch 3 pending; the third Ack crosses the
+	// compactThreshold of 3 and should trigger a rewrite.
+	if err := spool.Ack(1); err != nil {
+		t.Fatalf("Ack(1): %v", err)
+	}
+	if err := spool.Ack(2); err != nil {
+		t.Fatalf("Ack(2): %v", err)
+	}
+	if err := spool.Ack(3); err != nil {
+		t.Fatalf("Ack(3): %v", err)
+	}
+
+	sizeAfterCompaction, err := fileSize(segmentPath)
+	if err != nil {
+		t.Fatalf("fileSize after compaction: %v", err)
+	}
+	if sizeAfterCompaction >= sizeBeforeCompaction {
+		t.Fatalf("expected compaction to shrink the segment file, got %d >= %d", sizeAfterCompaction, sizeBeforeCompaction)
+	}
+
+	pending, err := spool.Pending()
+	if err != nil {
+		t.Fatalf("Pending after compaction: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("expected no pending batches after all 3 were acked, got %d: %+v", len(pending), pending)
+	}
+
+	if err := spool.Write(4, SpooledBatch{LogType: "Foo", TimeField: "TimeGenerated", Items: []interface{}{map[string]interface{}{"id": float64(4)}}}); err != nil {
+		t.Fatalf("Write(4): %v", err)
+	}
+	pending, err = spool.Pending()
+	if err != nil {
+		t.Fatalf("Pending after post-compaction write: %v", err)
+	}
+	if _, ok := pending[4]; !ok {
+		t.Fatalf("expected batch 4 to be pending after compaction, got %+v", pending)
+	}
+}
+
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestBuilder builds the outgoing POST request for an already-serialized
+// (and possibly gzip'd) batch payload. Batching, retry, gzip and metrics are
+// shared across every backend in send()/sendWithRetry(); only request
+// construction and auth differ between them. logType and timeField route the
+// batch to a table and TimeGenerated field; an empty logType means "this
+// transport's own default table".
+type requestBuilder interface {
+	buildRequest(ctx context.Context, payload []byte, contentLength int, gzipped bool, logType string, timeField string) (*http.Request, error)
+}
+
+// classicTransport builds requests for Azure Monitor's HTTP Data Collector
+// API, authenticating with the workspace's shared key. Microsoft has
+// announced this API's deprecation in favor of the DCR/DCE-based Logs
+// Ingestion API; see aadIngestionTransport.
+type classicTransport struct {
+	workspaceId string
+	sharedKey   []byte
+	logName     string
+	url         string
+}
+
+func (this *classicTransport)buildRequest(ctx context.Context, payload []byte, contentLength int, gzipped bool, logType string, timeField string) (*http.Request, error) {
+	if logType == "" {
+		logType = this.logName
+	}
+
+	dateString := time.Now().UTC().Format(signatureDateFormat)
+	dateString = strings.Replace(dateString, "UTC", "GMT", -1)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", this.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Log-Type", logType)
+	req.Header.Set("Authorization", this.generateAuthorization("POST", contentLength, dateString))
+	req.Header.Set("x-ms-date", dateString)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("time-generated-field", timeField)
+	if gzipped {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	return req, nil
+}
+
+func (this *classicTransport)generateAuthorization(method string, bodyLength int, dateString string) string {
+	stringToHash := method + "\n" + strconv.Itoa(bodyLength) + "\napplication/json\n" + "x-ms-date:" + dateString + "\n/api/logs"
+	return "SharedKey " + this.workspaceId + ":" + this.buildSignature(stringToHash)
+}
+
+func (this *classicTransport)buildSignature(message string) string {
+	h := hmac.New(sha256.New, this.sharedKey)
+	h.Write([]byte(message))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}